@@ -6,11 +6,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"leaderboard/leaderboard"
+	"leaderboard/metrics"
 	"leaderboard/websocket"
 )
 
@@ -25,16 +26,21 @@ type Event struct {
 
 // Subscriber handles event stream subscription
 type Subscriber struct {
-	apiKey      string
-	projectID   string
-	baseURL     string
-	calculator  *leaderboard.Calculator
-	hub         *websocket.Hub
-	httpClient  *http.Client
+	apiKey     string
+	projectID  string
+	baseURL    string
+	calculator *leaderboard.Calculator
+	hub        *websocket.Hub
+	httpClient *http.Client
+	logger     *slog.Logger
 }
 
-// NewSubscriber creates a new event subscriber
-func NewSubscriber(apiKey, projectID, baseURL string, calculator *leaderboard.Calculator, hub *websocket.Hub) *Subscriber {
+// NewSubscriber creates a new event subscriber. A nil logger falls back to
+// slog.Default().
+func NewSubscriber(apiKey, projectID, baseURL string, calculator *leaderboard.Calculator, hub *websocket.Hub, logger *slog.Logger) *Subscriber {
+	if logger == nil {
+		logger = slog.Default()
+	}
 	return &Subscriber{
 		apiKey:     apiKey,
 		projectID:  projectID,
@@ -44,6 +50,7 @@ func NewSubscriber(apiKey, projectID, baseURL string, calculator *leaderboard.Ca
 		httpClient: &http.Client{
 			Timeout: 0, // No timeout for SSE connections
 		},
+		logger: logger,
 	}
 }
 
@@ -54,11 +61,11 @@ func (s *Subscriber) Subscribe(ctx context.Context) error {
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("Event subscription cancelled")
+			s.logger.Info("event subscription cancelled")
 			return ctx.Err()
 		default:
 			if err := s.subscribeWithRetry(ctx, eventTypes); err != nil {
-				log.Printf("Subscription error: %v, retrying in 5 seconds...", err)
+				s.logger.Warn("subscription error, retrying in 5 seconds", "error", err)
 				time.Sleep(5 * time.Second)
 			}
 		}
@@ -99,7 +106,7 @@ func (s *Subscriber) subscribeWithRetry(ctx context.Context, eventTypes []string
 		return fmt.Errorf("subscription failed with status %d: %s", resp.StatusCode, string(bodyBytes))
 	}
 
-	log.Printf("Connected to event stream, listening for: %v", eventTypes)
+	s.logger.Info("connected to event stream", "event_types", eventTypes)
 
 	// Read SSE stream
 	reader := bufio.NewReader(resp.Body)
@@ -111,7 +118,7 @@ func (s *Subscriber) subscribeWithRetry(ctx context.Context, eventTypes []string
 			line, err := reader.ReadBytes('\n')
 			if err != nil {
 				if err == io.EOF {
-					log.Println("Event stream closed by server")
+					s.logger.Info("event stream closed by server")
 					return nil
 				}
 				return fmt.Errorf("error reading event stream: %w", err)
@@ -130,16 +137,17 @@ func (s *Subscriber) subscribeWithRetry(ctx context.Context, eventTypes []string
 func (s *Subscriber) handleEvent(data []byte) {
 	var event Event
 	if err := json.Unmarshal(data, &event); err != nil {
-		log.Printf("Error parsing event: %v", err)
+		s.logger.Warn("error parsing event", "error", err)
 		return
 	}
 
-	log.Printf("Received event: type=%s, id=%s", event.Type, event.ID)
+	metrics.EventsReceivedTotal.WithLabelValues(event.Type).Inc()
+	s.logger.Info("received event", "event_id", event.ID, "type", event.Type)
 
 	// Extract hackathon ID from event data
 	hackathonID, ok := event.Data["hackathon_id"].(string)
 	if !ok {
-		log.Printf("Event missing hackathon_id: %+v", event.Data)
+		s.logger.Warn("event missing hackathon_id", "event_id", event.ID, "data", event.Data)
 		return
 	}
 
@@ -150,13 +158,14 @@ func (s *Subscriber) handleEvent(data []byte) {
 	case "submission.created":
 		s.handleSubmissionCreated(hackathonID, event)
 	default:
-		log.Printf("Unknown event type: %s", event.Type)
+		s.logger.Warn("unknown event type", "event_id", event.ID, "type", event.Type)
 	}
 }
 
 // handleScoreSubmitted processes score submission events
 func (s *Subscriber) handleScoreSubmitted(hackathonID string, event Event) {
-	log.Printf("Processing score submission for hackathon %s", hackathonID)
+	start := time.Now()
+	s.logger.Info("processing score submission", "hackathon_id", hackathonID, "event_id", event.ID)
 
 	// Invalidate cache to force fresh calculation
 	s.calculator.InvalidateCache(hackathonID)
@@ -164,17 +173,19 @@ func (s *Subscriber) handleScoreSubmitted(hackathonID string, event Event) {
 	// Calculate updated leaderboard
 	rankings, err := s.calculator.CalculateLeaderboard(hackathonID)
 	if err != nil {
-		log.Printf("Error calculating leaderboard: %v", err)
+		s.logger.Error("error calculating leaderboard", "hackathon_id", hackathonID, "event_id", event.ID, "error", err)
 		return
 	}
 
 	// Broadcast update to all connected clients
 	s.broadcastUpdate(hackathonID, rankings)
+	metrics.EventsProcessingDuration.Observe(time.Since(start).Seconds())
 }
 
 // handleSubmissionCreated processes new submission events
 func (s *Subscriber) handleSubmissionCreated(hackathonID string, event Event) {
-	log.Printf("Processing new submission for hackathon %s", hackathonID)
+	start := time.Now()
+	s.logger.Info("processing new submission", "hackathon_id", hackathonID, "event_id", event.ID)
 
 	// Invalidate cache to force fresh calculation
 	s.calculator.InvalidateCache(hackathonID)
@@ -182,30 +193,156 @@ func (s *Subscriber) handleSubmissionCreated(hackathonID string, event Event) {
 	// Calculate updated leaderboard
 	rankings, err := s.calculator.CalculateLeaderboard(hackathonID)
 	if err != nil {
-		log.Printf("Error calculating leaderboard: %v", err)
+		s.logger.Error("error calculating leaderboard", "hackathon_id", hackathonID, "event_id", event.ID, "error", err)
 		return
 	}
 
 	// Broadcast update to all connected clients
 	s.broadcastUpdate(hackathonID, rankings)
+	metrics.EventsProcessingDuration.Observe(time.Since(start).Seconds())
 }
 
-// broadcastUpdate sends leaderboard updates to WebSocket clients
+// broadcastUpdate sends leaderboard updates to clients. Public subscribers
+// get the aggregated rankings; judge and organizer subscribers additionally
+// get the raw per-judge scores behind them. Subscribers with an active
+// track/top-N filter get a variant scoped to it, sent as an incremental
+// leaderboard_delta when that is smaller than resending their whole
+// filtered view, and as a full leaderboard_update otherwise.
 func (s *Subscriber) broadcastUpdate(hackathonID string, rankings []leaderboard.LeaderboardEntry) {
-	message := map[string]interface{}{
-		"type":      "leaderboard_update",
-		"data":      rankings,
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	seq := s.hub.NextSequence(hackathonID)
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+
+	scores, scoresErr := s.calculator.FetchRawScores(hackathonID)
+	if scoresErr != nil {
+		s.logger.Warn("error fetching raw scores for privileged broadcast", "hackathon_id", hackathonID, "error", scoresErr)
 	}
 
-	data, err := json.Marshal(message)
+	fallback, err := buildMessagePayloads("leaderboard_update", seq, timestamp, rankings, scores, scoresErr)
 	if err != nil {
-		log.Printf("Error marshaling leaderboard update: %v", err)
+		s.logger.Error("error marshaling leaderboard update", "hackathon_id", hackathonID, "error", err)
 		return
 	}
 
+	previous, hasPrevious := s.calculator.PreviousSnapshot(hackathonID)
+	variants := make(websocket.FilteredPayloads)
+	for _, filter := range s.hub.ActiveFilters(hackathonID) {
+		filtered := applyFilter(rankings, filter)
+
+		if hasPrevious {
+			delta := leaderboard.Diff(applyFilter(previous, filter), filtered)
+			if delta.Size() > 0 && delta.Size() < len(filtered) {
+				payloads, err := buildDeltaPayloads(delta, seq, timestamp, scores, scoresErr)
+				if err != nil {
+					s.logger.Error("error marshaling leaderboard delta", "hackathon_id", hackathonID, "filter", filter.Key(), "error", err)
+					continue
+				}
+				variants[filter.Key()] = payloads
+				continue
+			}
+		}
+
+		payloads, err := buildMessagePayloads("leaderboard_update", seq, timestamp, filtered, scores, scoresErr)
+		if err != nil {
+			s.logger.Error("error marshaling leaderboard update", "hackathon_id", hackathonID, "filter", filter.Key(), "error", err)
+			continue
+		}
+		variants[filter.Key()] = payloads
+	}
+
 	clientCount := s.hub.GetClientCount(hackathonID)
-	log.Printf("Broadcasting leaderboard update to %d clients for hackathon %s", clientCount, hackathonID)
+	s.logger.Info("broadcasting leaderboard update", "hackathon_id", hackathonID, "seq", seq, "client_count", clientCount)
+
+	s.hub.BroadcastFiltered(hackathonID, seq, fallback, variants)
+}
+
+// applyFilter narrows rankings to the tracks named by filter.Tracks (all
+// tracks if empty) and caps the result to filter.Top entries (no cap if 0).
+func applyFilter(rankings []leaderboard.LeaderboardEntry, filter websocket.Filter) []leaderboard.LeaderboardEntry {
+	out := rankings
+	if len(filter.Tracks) > 0 {
+		wanted := make(map[string]bool, len(filter.Tracks))
+		for _, track := range filter.Tracks {
+			wanted[track] = true
+		}
+		out = make([]leaderboard.LeaderboardEntry, 0, len(rankings))
+		for _, entry := range rankings {
+			if wanted[entry.TrackID] {
+				out = append(out, entry)
+			}
+		}
+	}
+	if filter.Top > 0 && len(out) > filter.Top {
+		out = out[:filter.Top]
+	}
+	return out
+}
 
-	s.hub.Broadcast(hackathonID, data)
+// buildMessagePayloads marshals a full leaderboard message of the given
+// type for both the public and privileged audiences, omitting the
+// privileged variant if scores could not be fetched.
+func buildMessagePayloads(msgType string, seq uint64, timestamp string, rankings []leaderboard.LeaderboardEntry, scores []leaderboard.Score, scoresErr error) (websocket.Payloads, error) {
+	publicData, err := json.Marshal(map[string]interface{}{
+		"type":      msgType,
+		"seq":       seq,
+		"data":      rankings,
+		"timestamp": timestamp,
+	})
+	if err != nil {
+		return websocket.Payloads{}, err
+	}
+
+	payloads := websocket.Payloads{Public: publicData}
+	if scoresErr != nil {
+		return payloads, nil
+	}
+
+	privilegedData, err := json.Marshal(map[string]interface{}{
+		"type":      msgType,
+		"seq":       seq,
+		"data":      rankings,
+		"scores":    scores,
+		"timestamp": timestamp,
+	})
+	if err != nil {
+		slog.Default().Error("error marshaling privileged leaderboard update", "error", err)
+		return payloads, nil
+	}
+	payloads.Privileged = privilegedData
+	return payloads, nil
+}
+
+// buildDeltaPayloads marshals an incremental leaderboard_delta message
+// carrying only the entries that were added or changed, plus the
+// SubmissionIDs that dropped out of a filtered view.
+func buildDeltaPayloads(delta leaderboard.Delta, seq uint64, timestamp string, scores []leaderboard.Score, scoresErr error) (websocket.Payloads, error) {
+	publicData, err := json.Marshal(map[string]interface{}{
+		"type":      "leaderboard_delta",
+		"seq":       seq,
+		"changes":   delta.Changes(),
+		"removed":   delta.Removed,
+		"timestamp": timestamp,
+	})
+	if err != nil {
+		return websocket.Payloads{}, err
+	}
+
+	payloads := websocket.Payloads{Public: publicData}
+	if scoresErr != nil {
+		return payloads, nil
+	}
+
+	privilegedData, err := json.Marshal(map[string]interface{}{
+		"type":      "leaderboard_delta",
+		"seq":       seq,
+		"changes":   delta.Changes(),
+		"removed":   delta.Removed,
+		"scores":    scores,
+		"timestamp": timestamp,
+	})
+	if err != nil {
+		slog.Default().Error("error marshaling privileged leaderboard delta", "error", err)
+		return payloads, nil
+	}
+	payloads.Privileged = privilegedData
+	return payloads, nil
 }
@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"sort"
 	"sync"
 	"time"
+
+	"leaderboard/metrics"
 )
 
 // Submission represents a hackathon submission
@@ -54,24 +57,32 @@ type cacheEntry struct {
 
 // Calculator handles leaderboard calculation logic
 type Calculator struct {
-	apiKey      string
-	projectID   string
-	baseURL     string
-	cache       map[string]*cacheEntry
-	cacheMutex  sync.RWMutex
-	cacheTTL    time.Duration
-	httpClient  *http.Client
+	apiKey     string
+	projectID  string
+	baseURL    string
+	cache      map[string]*cacheEntry
+	previous   map[string][]LeaderboardEntry
+	cacheMutex sync.RWMutex
+	cacheTTL   time.Duration
+	httpClient *http.Client
+	logger     *slog.Logger
 }
 
-// NewCalculator creates a new Calculator instance
-func NewCalculator(apiKey, projectID, baseURL string) *Calculator {
+// NewCalculator creates a new Calculator instance. A nil logger falls back
+// to slog.Default().
+func NewCalculator(apiKey, projectID, baseURL string, logger *slog.Logger) *Calculator {
+	if logger == nil {
+		logger = slog.Default()
+	}
 	return &Calculator{
 		apiKey:     apiKey,
 		projectID:  projectID,
 		baseURL:    baseURL,
 		cache:      make(map[string]*cacheEntry),
+		previous:   make(map[string][]LeaderboardEntry),
 		cacheTTL:   5 * time.Second,
 		httpClient: &http.Client{Timeout: 10 * time.Second},
+		logger:     logger,
 	}
 }
 
@@ -79,8 +90,11 @@ func NewCalculator(apiKey, projectID, baseURL string) *Calculator {
 func (c *Calculator) CalculateLeaderboard(hackathonID string) ([]LeaderboardEntry, error) {
 	// Check cache first
 	if rankings := c.getFromCache(hackathonID); rankings != nil {
+		metrics.CalculatorCacheHitsTotal.Inc()
 		return rankings, nil
 	}
+	metrics.CalculatorCacheMissesTotal.Inc()
+	c.logger.Debug("leaderboard cache miss, recalculating", "hackathon_id", hackathonID)
 
 	// Fetch submissions
 	submissions, err := c.fetchSubmissions(hackathonID)
@@ -148,13 +162,36 @@ func (c *Calculator) CalculateLeaderboard(hackathonID string) ([]LeaderboardEntr
 	return rankings, nil
 }
 
-// InvalidateCache removes a hackathon from the cache
+// FetchRawScores retrieves every judge score for a hackathon, including
+// judge identities, for callers authorized to see more than the aggregated
+// leaderboard (e.g. judge-tier broadcast payloads).
+func (c *Calculator) FetchRawScores(hackathonID string) ([]Score, error) {
+	return c.fetchScores(hackathonID)
+}
+
+// InvalidateCache removes a hackathon from the cache, stashing the outgoing
+// rankings as its previous snapshot so callers can diff against them once
+// the next CalculateLeaderboard repopulates the cache.
 func (c *Calculator) InvalidateCache(hackathonID string) {
 	c.cacheMutex.Lock()
 	defer c.cacheMutex.Unlock()
+	if entry, exists := c.cache[hackathonID]; exists {
+		c.previous[hackathonID] = entry.rankings
+	}
 	delete(c.cache, hackathonID)
 }
 
+// PreviousSnapshot returns the rankings stashed for hackathonID by the most
+// recent InvalidateCache call that found a cached entry, for callers that
+// want to compute an incremental diff against the new leaderboard. It
+// returns false if no prior snapshot has been recorded yet.
+func (c *Calculator) PreviousSnapshot(hackathonID string) ([]LeaderboardEntry, bool) {
+	c.cacheMutex.RLock()
+	defer c.cacheMutex.RUnlock()
+	rankings, ok := c.previous[hackathonID]
+	return rankings, ok
+}
+
 // getFromCache retrieves cached leaderboard if not expired
 func (c *Calculator) getFromCache(hackathonID string) []LeaderboardEntry {
 	c.cacheMutex.RLock()
@@ -203,7 +240,9 @@ func (c *Calculator) fetchSubmissions(hackathonID string) ([]Submission, error)
 	req.Header.Set("Authorization", "Bearer "+c.apiKey)
 	req.Header.Set("Content-Type", "application/json")
 
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
+	metrics.ZeroDBRequestDuration.WithLabelValues("submissions").Observe(time.Since(start).Seconds())
 	if err != nil {
 		return nil, err
 	}
@@ -267,7 +306,9 @@ func (c *Calculator) fetchScores(hackathonID string) ([]Score, error) {
 	req.Header.Set("Authorization", "Bearer "+c.apiKey)
 	req.Header.Set("Content-Type", "application/json")
 
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
+	metrics.ZeroDBRequestDuration.WithLabelValues("scores").Observe(time.Since(start).Seconds())
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,69 @@
+package leaderboard
+
+// Delta represents the entries that changed between two leaderboard
+// snapshots, for broadcasting incremental updates instead of a full
+// leaderboard_update on every recalculation. Added and Changed entries are
+// both keyed by SubmissionID, as are the IDs in Removed.
+type Delta struct {
+	Added   []LeaderboardEntry `json:"added"`
+	Changed []LeaderboardEntry `json:"changed"`
+	Removed []string           `json:"removed"`
+}
+
+// Changes returns the Added and Changed entries together, the shape clients
+// actually render: new rows to insert and existing rows to update in place.
+func (d Delta) Changes() []LeaderboardEntry {
+	return append(append([]LeaderboardEntry{}, d.Added...), d.Changed...)
+}
+
+// Size returns the number of entries a delta touches, for comparing against
+// a full snapshot's size to decide whether sending the delta is worthwhile.
+func (d Delta) Size() int {
+	return len(d.Added) + len(d.Changed) + len(d.Removed)
+}
+
+// Diff compares a previous leaderboard snapshot against the current one and
+// returns the submissions that are new, the ones whose rank, score, or
+// other displayed fields changed, and the IDs that dropped out entirely. Comparison ignores UpdatedAt, since
+// that field is stamped on every recalculation regardless of whether a
+// submission's standing actually moved.
+func Diff(previous, current []LeaderboardEntry) Delta {
+	prevByID := make(map[string]LeaderboardEntry, len(previous))
+	for _, entry := range previous {
+		prevByID[entry.SubmissionID] = entry
+	}
+
+	var delta Delta
+	currByID := make(map[string]bool, len(current))
+	for _, entry := range current {
+		currByID[entry.SubmissionID] = true
+		prior, existed := prevByID[entry.SubmissionID]
+		switch {
+		case !existed:
+			delta.Added = append(delta.Added, entry)
+		case !entriesEqual(prior, entry):
+			delta.Changed = append(delta.Changed, entry)
+		}
+	}
+
+	for id := range prevByID {
+		if !currByID[id] {
+			delta.Removed = append(delta.Removed, id)
+		}
+	}
+
+	return delta
+}
+
+// entriesEqual reports whether two entries describe the same leaderboard
+// standing, disregarding UpdatedAt.
+func entriesEqual(a, b LeaderboardEntry) bool {
+	return a.Rank == b.Rank &&
+		a.TeamID == b.TeamID &&
+		a.TeamName == b.TeamName &&
+		a.TrackID == b.TrackID &&
+		a.TrackName == b.TrackName &&
+		a.Title == b.Title &&
+		a.AverageScore == b.AverageScore &&
+		a.ScoreCount == b.ScoreCount
+}
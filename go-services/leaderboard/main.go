@@ -4,15 +4,21 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
+	natsgo "github.com/nats-io/nats.go"
+
 	"leaderboard/events"
 	"leaderboard/leaderboard"
+	"leaderboard/metrics"
+	"leaderboard/peer"
 	"leaderboard/websocket"
 )
 
@@ -27,21 +33,33 @@ func main() {
 	zeroDBAPIKey := os.Getenv("ZERODB_API_KEY")
 	zeroDBProjectID := os.Getenv("ZERODB_PROJECT_ID")
 	zeroDBBaseURL := getEnv("ZERODB_BASE_URL", "https://api.ainative.studio")
+	replayBufferSize := getEnvInt("LEADERBOARD_REPLAY_BUFFER_SIZE", websocket.DefaultReplayBufferSize)
 
 	if zeroDBAPIKey == "" || zeroDBProjectID == "" {
 		log.Fatal("ZERODB_API_KEY and ZERODB_PROJECT_ID must be set")
 	}
 
+	// Structured logger shared by the hub, calculator, and event subscriber,
+	// so every log line from the hot paths carries hackathon_id, client_id,
+	// or event_id for correlation.
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	slog.SetDefault(logger)
+
 	// Initialize components
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Create WebSocket hub for managing client connections
-	hub := websocket.NewHub()
+	hub := websocket.NewHub(logger)
+	hub.SetReplayBufferSize(replayBufferSize)
 	go hub.Run()
 
+	// Optionally federate with other instances of this service so a client
+	// connected to any node sees every node's broadcasts
+	nodePeer := setupPeer(ctx, hub)
+
 	// Create leaderboard calculator
-	calculator := leaderboard.NewCalculator(zeroDBAPIKey, zeroDBProjectID, zeroDBBaseURL)
+	calculator := leaderboard.NewCalculator(zeroDBAPIKey, zeroDBProjectID, zeroDBBaseURL, logger)
 
 	// Create event subscriber
 	subscriber := events.NewSubscriber(
@@ -50,6 +68,7 @@ func main() {
 		zeroDBBaseURL,
 		calculator,
 		hub,
+		logger,
 	)
 
 	// Start event subscription in background
@@ -64,6 +83,17 @@ func main() {
 		websocket.ServeWS(hub, calculator, w, r)
 	})
 
+	http.HandleFunc("/sse/hackathons/", func(w http.ResponseWriter, r *http.Request) {
+		websocket.ServeSSE(hub, calculator, w, r)
+	})
+
+	if nodePeer != nil {
+		http.HandleFunc("/v1/peers", nodePeer.ServePeers)
+		http.HandleFunc("/v1/peers/subscribe", nodePeer.ServePeersSubscribe)
+	}
+
+	http.Handle("/metrics", metrics.Handler())
+
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
@@ -104,6 +134,62 @@ func main() {
 	}
 }
 
+// setupPeer builds and attaches a federation Peer to hub based on
+// PEER_TRANSPORT ("redis", "nats", or unset to disable), so multiple
+// replicas of this service can mirror each other's broadcasts. It returns
+// nil if federation is not configured.
+func setupPeer(ctx context.Context, hub *websocket.Hub) *websocket.Peer {
+	transportKind := getEnv("PEER_TRANSPORT", "")
+	if transportKind == "" {
+		return nil
+	}
+
+	nodeID := getEnv("NODE_ID", hostnameOrRandom())
+	channel := getEnv("PEER_CHANNEL", "leaderboard.broadcasts")
+
+	var transport peer.Transport
+	switch transportKind {
+	case "redis":
+		transport = peer.NewRedisTransport(
+			getEnv("PEER_REDIS_ADDR", "localhost:6379"),
+			getEnv("PEER_REDIS_PASSWORD", ""),
+			getEnvInt("PEER_REDIS_DB", 0),
+			channel,
+		)
+	case "nats":
+		conn, err := natsgo.Connect(getEnv("PEER_NATS_URL", natsgo.DefaultURL))
+		if err != nil {
+			log.Printf("Failed to connect to NATS, federation disabled: %v", err)
+			return nil
+		}
+		transport = peer.NewNATSTransport(conn, channel)
+	default:
+		log.Printf("Unknown PEER_TRANSPORT %q, federation disabled", transportKind)
+		return nil
+	}
+
+	nodePeer := websocket.NewPeer(nodeID, hub, transport)
+	hub.AttachPeer(nodePeer)
+
+	go func() {
+		if err := nodePeer.Run(ctx); err != nil && err != context.Canceled {
+			log.Printf("Peer subscription ended: %v", err)
+		}
+	}()
+
+	log.Printf("Federation enabled via %s as node %s", transportKind, nodeID)
+	return nodePeer
+}
+
+// hostnameOrRandom returns the machine's hostname, falling back to an ID
+// derived from the process ID if it cannot be determined.
+func hostnameOrRandom() string {
+	if name, err := os.Hostname(); err == nil && name != "" {
+		return name
+	}
+	return fmt.Sprintf("node-%d", os.Getpid())
+}
+
 // getEnv retrieves an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -111,3 +197,18 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvInt retrieves an integer environment variable or returns a default
+// value if it is unset or not a valid integer.
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid value for %s=%q, using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
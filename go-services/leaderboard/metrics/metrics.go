@@ -0,0 +1,75 @@
+// Package metrics holds the Prometheus collectors shared by the hub,
+// event subscriber, and leaderboard calculator, so the service can run
+// behind a load balancer with SLOs on broadcast and event-processing
+// latency.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// HubClientsTotal tracks currently connected subscribers per hackathon.
+	HubClientsTotal = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "hub_clients_total",
+		Help: "Number of subscribers currently connected, by hackathon.",
+	}, []string{"hackathon"})
+
+	// HubBroadcastDropped counts broadcasts lost because the hub's
+	// internal queue was full.
+	HubBroadcastDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "hub_broadcast_dropped_total",
+		Help: "Broadcasts dropped because the hub's internal queue was full.",
+	})
+
+	// HubBroadcastDuration measures how long it takes to fan a single
+	// broadcast out to every subscriber of a hackathon.
+	HubBroadcastDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "hub_broadcast_duration_seconds",
+		Help:    "Time spent fanning out one broadcast to all subscribers of a hackathon.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	// EventsReceivedTotal counts ZeroDB events the subscriber has seen, by
+	// event type.
+	EventsReceivedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "events_received_total",
+		Help: "ZeroDB events received by the subscriber, by event type.",
+	}, []string{"type"})
+
+	// EventsProcessingDuration measures how long it takes to recalculate
+	// and broadcast a leaderboard update after an event arrives.
+	EventsProcessingDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "events_processing_duration_seconds",
+		Help:    "Time spent recalculating and broadcasting a leaderboard update after an event.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	// CalculatorCacheHitsTotal and CalculatorCacheMissesTotal track the
+	// Calculator's in-memory leaderboard cache effectiveness.
+	CalculatorCacheHitsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "calculator_cache_hits_total",
+		Help: "Leaderboard calculations served from the in-memory cache.",
+	})
+	CalculatorCacheMissesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "calculator_cache_misses_total",
+		Help: "Leaderboard calculations that required a fresh fetch from ZeroDB.",
+	})
+
+	// ZeroDBRequestDuration measures ZeroDB HTTP request latency, by
+	// endpoint.
+	ZeroDBRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "zerodb_request_duration_seconds",
+		Help:    "Latency of HTTP requests made to ZeroDB, by endpoint.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint"})
+)
+
+// Handler returns the HTTP handler to expose at /metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
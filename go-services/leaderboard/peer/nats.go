@@ -0,0 +1,53 @@
+package peer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSTransport relays Envelopes between nodes over a NATS subject.
+type NATSTransport struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// NewNATSTransport creates a NATSTransport publishing and subscribing on a
+// single shared subject.
+func NewNATSTransport(conn *nats.Conn, subject string) *NATSTransport {
+	return &NATSTransport{conn: conn, subject: subject}
+}
+
+// Publish implements Transport.
+func (t *NATSTransport) Publish(ctx context.Context, env Envelope) error {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("marshal envelope: %w", err)
+	}
+	return t.conn.Publish(t.subject, data)
+}
+
+// Subscribe implements Transport.
+func (t *NATSTransport) Subscribe(ctx context.Context, handler func(Envelope)) error {
+	msgs := make(chan *nats.Msg, 256)
+	sub, err := t.conn.ChanSubscribe(t.subject, msgs)
+	if err != nil {
+		return fmt.Errorf("subscribe to %s: %w", t.subject, err)
+	}
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg := <-msgs:
+			var env Envelope
+			if err := json.Unmarshal(msg.Data, &env); err != nil {
+				continue
+			}
+			handler(env)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+// Package peer lets multiple instances of the leaderboard service mirror
+// each other's broadcasts, so a client connected to one node still receives
+// updates triggered by an event that arrived at another node.
+package peer
+
+import "context"
+
+// Envelope is a broadcast as relayed between mesh nodes. It mirrors
+// websocket.Payloads plus the routing fields a remote node needs to fan it
+// out locally.
+type Envelope struct {
+	NodeID      string `json:"node_id"`
+	HackathonID string `json:"hackathon_id"`
+	Seq         uint64 `json:"seq"`
+	Public      []byte `json:"public"`
+	Privileged  []byte `json:"privileged,omitempty"`
+}
+
+// Transport moves Envelopes between instances of this service. Implementations
+// wrap a pub/sub system (Redis, NATS, ...) so the rest of the service never
+// has to know which one is in use.
+type Transport interface {
+	// Publish sends an envelope to every node listening on the topic,
+	// including the publishing node itself.
+	Publish(ctx context.Context, env Envelope) error
+
+	// Subscribe delivers every envelope published on the topic, this
+	// node's own included, to handler until ctx is cancelled or the
+	// underlying subscription ends. It blocks, so callers should run it in
+	// a goroutine.
+	Subscribe(ctx context.Context, handler func(Envelope)) error
+}
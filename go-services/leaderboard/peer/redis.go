@@ -0,0 +1,60 @@
+package peer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisTransport relays Envelopes between nodes using Redis Pub/Sub.
+type RedisTransport struct {
+	client  *redis.Client
+	channel string
+}
+
+// NewRedisTransport creates a RedisTransport publishing and subscribing on
+// a single shared channel.
+func NewRedisTransport(addr, password string, db int, channel string) *RedisTransport {
+	return &RedisTransport{
+		client: redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: password,
+			DB:       db,
+		}),
+		channel: channel,
+	}
+}
+
+// Publish implements Transport.
+func (t *RedisTransport) Publish(ctx context.Context, env Envelope) error {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("marshal envelope: %w", err)
+	}
+	return t.client.Publish(ctx, t.channel, data).Err()
+}
+
+// Subscribe implements Transport.
+func (t *RedisTransport) Subscribe(ctx context.Context, handler func(Envelope)) error {
+	sub := t.client.Subscribe(ctx, t.channel)
+	defer sub.Close()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			var env Envelope
+			if err := json.Unmarshal([]byte(msg.Payload), &env); err != nil {
+				continue
+			}
+			handler(env)
+		}
+	}
+}
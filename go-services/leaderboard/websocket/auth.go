@@ -0,0 +1,143 @@
+package websocket
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Role identifies the privilege tier granted to an authenticated subscriber.
+type Role string
+
+const (
+	RolePublic    Role = "public"
+	RoleJudge     Role = "judge"
+	RoleOrganizer Role = "organizer"
+)
+
+// authProtocol is the WebSocket subprotocol clients negotiate to carry a
+// signed token alongside the upgrade request.
+const authProtocol = "leaderboard.v1.jwt"
+
+// firstFrameDeadline bounds how long ServeWS waits for an optional
+// {"op":"auth",...} frame before treating the connection as public.
+const firstFrameDeadline = 2 * time.Second
+
+// Claims are the JWT fields this service trusts for access control.
+type Claims struct {
+	HackathonID string `json:"hackathon_id"`
+	Role        Role   `json:"role"`
+	Exp         int64  `json:"exp"`
+}
+
+var errInvalidToken = errors.New("invalid or expired token")
+
+// authenticate determines the caller's role for hackathonID. A bearer token
+// negotiated via the leaderboard.v1.jwt subprotocol is validated and its
+// role trusted; absent that, the connection authenticates as public and may
+// still elevate its role via a first client frame (see
+// tryUpgradeViaFirstFrame).
+func authenticate(hackathonID string, r *http.Request) (*Claims, error) {
+	if token := tokenFromSubprotocol(r); token != "" {
+		return verifyToken(hackathonID, token)
+	}
+	return &Claims{HackathonID: hackathonID, Role: RolePublic}, nil
+}
+
+// tokenFromSubprotocol extracts the bearer token from a negotiated
+// "Sec-WebSocket-Protocol: leaderboard.v1.jwt, <token>" header.
+func tokenFromSubprotocol(r *http.Request) string {
+	header := r.Header.Get("Sec-WebSocket-Protocol")
+	if header == "" {
+		return ""
+	}
+
+	var sawAuthProtocol bool
+	var token string
+	for _, p := range strings.Split(header, ",") {
+		p = strings.TrimSpace(p)
+		switch {
+		case p == authProtocol:
+			sawAuthProtocol = true
+		case p != "":
+			token = p
+		}
+	}
+	if !sawAuthProtocol {
+		return ""
+	}
+	return token
+}
+
+// verifyFirstFrame validates a token carried in a client's first data frame,
+// {"op":"auth","token":"..."}, used when a client cannot set
+// Sec-WebSocket-Protocol (e.g. browser EventSource-style consumers).
+func verifyFirstFrame(hackathonID string, frame []byte) (*Claims, error) {
+	var msg struct {
+		Op    string `json:"op"`
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(frame, &msg); err != nil {
+		return nil, fmt.Errorf("malformed auth frame: %w", err)
+	}
+	if msg.Op != "auth" {
+		return nil, errors.New("expected auth frame")
+	}
+	return verifyToken(hackathonID, msg.Token)
+}
+
+// verifyToken validates an HMAC-signed JWT against LEADERBOARD_JWT_SECRET
+// and checks its hackathon_id and exp claims. Each claim is checked only
+// when present: a token without exp does not expire, and a token without
+// hackathon_id is accepted for any hackathon.
+func verifyToken(hackathonID, token string) (*Claims, error) {
+	secret := os.Getenv("LEADERBOARD_JWT_SECRET")
+	if secret == "" || token == "" {
+		return nil, errInvalidToken
+	}
+
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		return nil, errInvalidToken
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(segments[2])
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(segments[0] + "." + segments[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, errInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(segments[1])
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errInvalidToken
+	}
+
+	if claims.Exp != 0 && time.Now().Unix() > claims.Exp {
+		return nil, errInvalidToken
+	}
+	if claims.HackathonID != "" && claims.HackathonID != hackathonID {
+		return nil, errInvalidToken
+	}
+	if claims.Role == "" {
+		claims.Role = RolePublic
+	}
+
+	return &claims, nil
+}
@@ -0,0 +1,425 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+
+	"leaderboard/leaderboard"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = (pongWait * 9) / 10
+	maxMessageSize = 4096
+
+	// writeIdleTimeout bounds how long writeDeadline waits for a write
+	// (data or ping) before treating the connection as dead. It must
+	// exceed pingPeriod, since an idle client with nothing broadcast to it
+	// is only kept alive by pings, not data sends.
+	writeIdleTimeout = pingPeriod + writeWait
+
+	// deliverTimeout bounds how long Deliver waits for room in a client's
+	// send buffer before treating it as a miss, instead of failing it on
+	// the first full buffer.
+	deliverTimeout = 50 * time.Millisecond
+
+	// maxConsecutiveMisses is how many deliveries in a row may time out
+	// before a lagging client is evicted.
+	maxConsecutiveMisses = 3
+)
+
+var errSlowConsumer = errors.New("subscriber send buffer full")
+
+// Filter narrows a subscriber's view of a hackathon's leaderboard to a set
+// of tracks and/or a top-N cutoff. A zero-value Filter (no tracks, no top)
+// matches everything and receives the same broadcast every other
+// unfiltered subscriber does.
+type Filter struct {
+	Tracks []string
+	Top    int
+}
+
+// Key canonicalizes a Filter into a string suitable for use as a map key,
+// so subscribers with equivalent filters share one computed variant. The
+// empty string is reserved for the zero-value (unfiltered) Filter.
+func (f Filter) Key() string {
+	if len(f.Tracks) == 0 && f.Top == 0 {
+		return ""
+	}
+	tracks := make([]string, len(f.Tracks))
+	copy(tracks, f.Tracks)
+	sort.Strings(tracks)
+	return fmt.Sprintf("tracks=%s&top=%d", strings.Join(tracks, ","), f.Top)
+}
+
+// parseFilter reads the ?tracks= (comma-separated) and ?top= query
+// parameters off a subscription request.
+func parseFilter(r *http.Request) Filter {
+	var filter Filter
+	if raw := r.URL.Query().Get("tracks"); raw != "" {
+		filter.Tracks = strings.Split(raw, ",")
+	}
+	if raw := r.URL.Query().Get("top"); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			filter.Top = n
+		}
+	}
+	return filter
+}
+
+var upgrader = gorilla.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+var clientSeq int64
+
+// Client is a WebSocket subscriber. It implements Subscriber so the Hub can
+// treat it the same as any other transport.
+type Client struct {
+	id          string
+	hub         *Hub
+	conn        *gorilla.Conn
+	hackathonID string
+	role        Role
+	filter      Filter
+	send        chan []byte
+	closeOnce   sync.Once
+
+	// writeDeadline and readDeadline fire if the client goes quiet for too
+	// long (no successful write in writeIdleTimeout, no pong in pongWait),
+	// closing the connection so its pumps unwind and the Hub unregisters
+	// it. writeDeadline is armed for writeIdleTimeout rather than the
+	// per-write writeWait because pings, not just data sends, reset it,
+	// and pings only fire every pingPeriod.
+	writeDeadline *time.Timer
+	readDeadline  *time.Timer
+
+	// lagging-consumer coalescing: while lagging, only the latest payload
+	// is kept instead of contending for send buffer space on every missed
+	// delivery. closed mirrors whether Close has run, so Deliver and
+	// tryFlushCoalesced never attempt to send on the now-closed send
+	// channel; both hold deliverMu for the full duration of their send
+	// attempt so they can't race Close's closing of send.
+	deliverMu sync.Mutex
+	closed    bool
+	lagging   bool
+	misses    int
+	coalesced []byte
+}
+
+func newClient(hub *Hub, conn *gorilla.Conn, hackathonID string, role Role, filter Filter) *Client {
+	id := atomic.AddInt64(&clientSeq, 1)
+	c := &Client{
+		id:          fmt.Sprintf("ws-%d", id),
+		hub:         hub,
+		conn:        conn,
+		hackathonID: hackathonID,
+		role:        role,
+		filter:      filter,
+		send:        make(chan []byte, 256),
+	}
+	c.writeDeadline = time.AfterFunc(writeIdleTimeout, c.onDeadlineExceeded)
+	c.readDeadline = time.AfterFunc(pongWait, c.onDeadlineExceeded)
+	return c
+}
+
+// ID identifies this client for logging and eviction.
+func (c *Client) ID() string { return c.id }
+
+// Role reports the privilege tier this client authenticated with.
+func (c *Client) Role() Role { return c.role }
+
+// Filter reports the track/top-N narrowing this client subscribed with.
+func (c *Client) Filter() Filter { return c.filter }
+
+// onDeadlineExceeded runs when writeDeadline or readDeadline fires without
+// having been reset by a successful send or pong. Closing the connection
+// unblocks readPump/writePump so they unregister the client.
+func (c *Client) onDeadlineExceeded() {
+	log.Printf("Client %s exceeded its deadline, closing", c.id)
+	c.conn.Close()
+}
+
+// Deliver queues data for the client's writePump, waiting up to
+// deliverTimeout for room rather than failing on the first full buffer.
+// Once a client is lagging, later payloads replace whatever is coalesced
+// instead of queueing behind it, since only the latest leaderboard state
+// matters — but every delivery that times out or arrives while lagging
+// still counts as a miss, so a consumer that never recovers is evicted after
+// maxConsecutiveMisses instead of lagging forever. Deliver holds deliverMu
+// for its full duration (including the blocking select) so it can never
+// race Close's closing of send.
+func (c *Client) Deliver(data []byte) error {
+	c.deliverMu.Lock()
+	defer c.deliverMu.Unlock()
+
+	if c.closed {
+		return errSlowConsumer
+	}
+
+	if c.lagging {
+		c.coalesced = data
+		return c.recordMissLocked()
+	}
+
+	timer := time.NewTimer(deliverTimeout)
+	defer timer.Stop()
+
+	select {
+	case c.send <- data:
+		c.misses = 0
+		return nil
+	case <-timer.C:
+		c.lagging = true
+		c.coalesced = data
+		return c.recordMissLocked()
+	}
+}
+
+// recordMissLocked increments the consecutive-miss counter and reports
+// errSlowConsumer once it reaches maxConsecutiveMisses. Callers must hold
+// deliverMu.
+func (c *Client) recordMissLocked() error {
+	c.misses++
+	if c.misses >= maxConsecutiveMisses {
+		return errSlowConsumer
+	}
+	return nil
+}
+
+// tryFlushCoalesced attempts to deliver a lagging client's pending snapshot
+// once writePump has drained room in the send buffer, clearing lagging mode
+// on success. It holds deliverMu for its full (non-blocking) attempt, same
+// as Deliver, so it can never race Close's closing of send.
+func (c *Client) tryFlushCoalesced() {
+	c.deliverMu.Lock()
+	defer c.deliverMu.Unlock()
+
+	if c.closed || !c.lagging || c.coalesced == nil {
+		return
+	}
+
+	select {
+	case c.send <- c.coalesced:
+		c.coalesced = nil
+		c.lagging = false
+		c.misses = 0
+	default:
+		// Still backed up; writePump retries after its next successful write.
+	}
+}
+
+// Close closes the client's send channel, signalling writePump to stop.
+// Marking closed under deliverMu before closing send ensures Deliver and
+// tryFlushCoalesced never attempt a send after the channel is closed.
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		c.writeDeadline.Stop()
+		c.readDeadline.Stop()
+		c.deliverMu.Lock()
+		c.closed = true
+		c.deliverMu.Unlock()
+		close(c.send)
+	})
+}
+
+// ServeWS upgrades an HTTP request to a WebSocket connection and registers
+// the resulting client with the hub for the hackathon named in the URL
+// (/ws/hackathons/{id}). Before promoting the connection, it authenticates
+// the caller via either the leaderboard.v1.jwt subprotocol handshake or, for
+// a client that opts in with ?auth=frame, a first {"op":"auth",...} frame,
+// defaulting to a public role when neither is present. The client then
+// receives buffered replay or a full snapshot, followed by live updates as
+// they are broadcast.
+func ServeWS(hub *Hub, calculator *leaderboard.Calculator, w http.ResponseWriter, r *http.Request) {
+	hackathonID := hackathonIDFromPath(r.URL.Path, "/ws/hackathons/")
+	if hackathonID == "" {
+		http.Error(w, "hackathon id required", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := authenticate(hackathonID, r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	u := upgrader
+	if claims.Role != RolePublic {
+		u.Subprotocols = []string{authProtocol}
+	}
+
+	conn, err := u.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+
+	if claims.Role == RolePublic && r.URL.Query().Get("auth") == "frame" {
+		if upgraded := tryUpgradeViaFirstFrame(conn, hackathonID); upgraded != nil {
+			claims = upgraded
+		}
+	}
+
+	client := newClient(hub, conn, hackathonID, claims.Role, parseFilter(r))
+	hub.Register(hackathonID, client)
+
+	deliverInitialState(client, hub, calculator, hackathonID, r)
+
+	go client.writePump()
+	go client.readPump()
+}
+
+// tryUpgradeViaFirstFrame gives a client that requested it via ?auth=frame a
+// short grace window to send an {"op":"auth","token":"..."} frame before
+// falling back to public access. It is never invoked for ordinary public
+// viewers, who would otherwise stall for up to firstFrameDeadline waiting
+// for a frame they never intended to send.
+func tryUpgradeViaFirstFrame(conn *gorilla.Conn, hackathonID string) *Claims {
+	conn.SetReadDeadline(time.Now().Add(firstFrameDeadline))
+	_, frame, err := conn.ReadMessage()
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		return nil
+	}
+
+	claims, err := verifyFirstFrame(hackathonID, frame)
+	if err != nil {
+		log.Printf("Ignoring invalid auth frame: %v", err)
+		return nil
+	}
+	return claims
+}
+
+// deliverInitialState replays buffered broadcasts since the client's
+// ?since= cursor, if present, otherwise sends a full leaderboard snapshot.
+// This closes the reconnect gap: a client that drops during a burst of
+// updates can resume from its last seen sequence instead of missing them.
+func deliverInitialState(sub Subscriber, hub *Hub, calculator *leaderboard.Calculator, hackathonID string, r *http.Request) {
+	if replayed := replayMessages(hub, hackathonID, r); len(replayed) > 0 {
+		for _, payloads := range replayed {
+			if err := sub.Deliver(payloads.For(sub.Role())); err != nil {
+				log.Printf("Failed to deliver replayed message to %s: %v", sub.ID(), err)
+				return
+			}
+		}
+		return
+	}
+	sendSnapshot(sub, calculator, hackathonID)
+}
+
+// replayMessages parses the ?since= query parameter, accepting either a
+// sequence number or an RFC3339 timestamp, and returns buffered broadcasts
+// newer than it. It returns nil if since is absent or malformed.
+func replayMessages(hub *Hub, hackathonID string, r *http.Request) []Payloads {
+	since := r.URL.Query().Get("since")
+	if since == "" {
+		return nil
+	}
+	if seq, err := strconv.ParseUint(since, 10, 64); err == nil {
+		return hub.ReplaySince(hackathonID, seq)
+	}
+	if ts, err := time.Parse(time.RFC3339, since); err == nil {
+		return hub.ReplaySinceTime(hackathonID, ts)
+	}
+	log.Printf("Ignoring malformed since cursor %q", since)
+	return nil
+}
+
+// sendSnapshot delivers the current leaderboard to a newly connected client.
+func sendSnapshot(sub Subscriber, calculator *leaderboard.Calculator, hackathonID string) {
+	rankings, err := calculator.CalculateLeaderboard(hackathonID)
+	if err != nil {
+		log.Printf("Error calculating snapshot for hackathon %s: %v", hackathonID, err)
+		return
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"type": "leaderboard_snapshot",
+		"data": rankings,
+	})
+	if err != nil {
+		log.Printf("Error marshaling snapshot: %v", err)
+		return
+	}
+
+	if err := sub.Deliver(data); err != nil {
+		log.Printf("Failed to deliver snapshot to %s: %v", sub.ID(), err)
+	}
+}
+
+// hackathonIDFromPath extracts the trailing path segment after prefix, e.g.
+// "/ws/hackathons/abc123" with prefix "/ws/hackathons/" yields "abc123".
+func hackathonIDFromPath(path, prefix string) string {
+	return strings.Trim(strings.TrimPrefix(path, prefix), "/")
+}
+
+func (c *Client) readPump() {
+	defer func() {
+		c.hub.Unregister(c.hackathonID, c)
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		c.readDeadline.Reset(pongWait)
+		return nil
+	})
+
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			if gorilla.IsUnexpectedCloseError(err, gorilla.CloseGoingAway, gorilla.CloseAbnormalClosure) {
+				log.Printf("Client %s closed unexpectedly: %v", c.id, err)
+			}
+			break
+		}
+		c.readDeadline.Reset(pongWait)
+	}
+}
+
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case data, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.conn.WriteMessage(gorilla.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(gorilla.TextMessage, data); err != nil {
+				return
+			}
+			c.writeDeadline.Reset(writeIdleTimeout)
+			c.tryFlushCoalesced()
+
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(gorilla.PingMessage, nil); err != nil {
+				return
+			}
+			c.writeDeadline.Reset(writeIdleTimeout)
+		}
+	}
+}
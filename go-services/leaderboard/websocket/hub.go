@@ -1,105 +1,234 @@
 package websocket
 
 import (
-	"log"
+	"log/slog"
 	"sync"
+	"time"
+
+	"leaderboard/metrics"
 )
 
-// Hub maintains the set of active clients and broadcasts messages to clients
+// DefaultReplayBufferSize is how many past broadcasts per hackathon are
+// kept in memory so a reconnecting client can replay what it missed.
+const DefaultReplayBufferSize = 128
+
+// Subscriber is anything the Hub can deliver a broadcast payload to,
+// regardless of the underlying transport (WebSocket, SSE, ...). The Hub
+// calls Deliver on a separate goroutine per delivery, never on its run
+// loop; an implementation that is falling behind should return an error so
+// the Hub can evict it.
+type Subscriber interface {
+	// ID uniquely identifies the subscriber for logging and eviction.
+	ID() string
+
+	// Role reports the privilege tier this subscriber authenticated with,
+	// determining which of a broadcast's payload variants it receives.
+	Role() Role
+
+	// Filter reports the track/top-N narrowing this subscriber wants, if
+	// any, determining which of a broadcast's filtered variants it
+	// receives in place of the unfiltered fallback.
+	Filter() Filter
+
+	// Deliver sends data to the subscriber.
+	Deliver(data []byte) error
+
+	// Close releases any resources held for this subscriber. The Hub can
+	// call it more than once (concurrent evictions of the same subscriber,
+	// or shutdown), so implementations must tolerate repeated calls.
+	Close()
+}
+
+// Hub maintains the set of active subscribers and broadcasts messages to
+// them, fanned out per hackathon.
 type Hub struct {
-	// Registered clients organized by hackathon ID
-	clients map[string]map[*Client]bool
+	// Registered subscribers organized by hackathon ID
+	clients map[string]map[Subscriber]bool
 
 	// Inbound messages from the clients
 	broadcast chan *BroadcastMessage
 
-	// Register requests from the clients
-	register chan *Client
+	// Register requests from subscribers
+	register chan registration
 
-	// Unregister requests from clients
-	unregister chan *Client
+	// Unregister requests from subscribers
+	unregister chan registration
 
 	// Mutex for thread-safe access to clients map
 	mu sync.RWMutex
 
 	// Shutdown signal
 	shutdown chan bool
+
+	// Per-hackathon sequence counters and replay ring buffers
+	topicMu          sync.Mutex
+	topics           map[string]*topic
+	replayBufferSize int
+
+	// Optional federation peer; nil unless AttachPeer is called
+	peer *Peer
+
+	logger *slog.Logger
+}
+
+// registration pairs a subscriber with the hackathon it is joining or
+// leaving, since Subscriber itself carries no topic information.
+type registration struct {
+	hackathonID string
+	subscriber  Subscriber
 }
 
 // BroadcastMessage represents a message to broadcast to clients
 type BroadcastMessage struct {
 	HackathonID string
-	Data        []byte
+	Seq         uint64
+	Payloads    Payloads
+	Variants    FilteredPayloads
+}
+
+// FilteredPayloads maps a Filter's Key to the Payloads computed for
+// subscribers using that filter. A subscriber whose filter has no entry
+// here falls back to the broadcast's unfiltered Payloads.
+type FilteredPayloads map[string]Payloads
+
+// Payloads holds the role-tiered variants of a single broadcast. Judge-only
+// details (raw scores, judge identities) go in Privileged; everyone else
+// gets the aggregated Public view.
+type Payloads struct {
+	Public     []byte
+	Privileged []byte
+}
+
+// For returns the payload variant a subscriber with the given role should
+// receive, falling back to Public when no privileged variant was built.
+func (p Payloads) For(role Role) []byte {
+	if role != RolePublic && p.Privileged != nil {
+		return p.Privileged
+	}
+	return p.Public
+}
+
+// topic tracks the monotonically increasing sequence counter and replay
+// buffer for a single hackathon's broadcast stream.
+type topic struct {
+	seq    uint64
+	buffer []bufferedMessage
+}
+
+// bufferedMessage is one retained broadcast, kept so reconnecting clients
+// can replay anything they missed.
+type bufferedMessage struct {
+	seq      uint64
+	payloads Payloads
+	storedAt time.Time
 }
 
-// NewHub creates a new Hub instance
-func NewHub() *Hub {
+// NewHub creates a new Hub instance with the default replay buffer size. A
+// nil logger falls back to slog.Default().
+func NewHub(logger *slog.Logger) *Hub {
+	if logger == nil {
+		logger = slog.Default()
+	}
 	return &Hub{
-		broadcast:  make(chan *BroadcastMessage, 256),
-		register:   make(chan *Client, 256),
-		unregister: make(chan *Client, 256),
-		clients:    make(map[string]map[*Client]bool),
-		shutdown:   make(chan bool),
+		broadcast:        make(chan *BroadcastMessage, 256),
+		register:         make(chan registration, 256),
+		unregister:       make(chan registration, 256),
+		clients:          make(map[string]map[Subscriber]bool),
+		shutdown:         make(chan bool),
+		topics:           make(map[string]*topic),
+		replayBufferSize: DefaultReplayBufferSize,
+		logger:           logger,
+	}
+}
+
+// AttachPeer wires a federation Peer into the Hub, so every local Broadcast
+// is also mirrored to other nodes in the mesh. It must be called before Run
+// starts handling broadcasts.
+func (h *Hub) AttachPeer(p *Peer) {
+	h.peer = p
+}
+
+// SetReplayBufferSize changes how many past broadcasts per hackathon are
+// retained for replay. It must be called before Run starts handling
+// broadcasts to take effect consistently.
+func (h *Hub) SetReplayBufferSize(size int) {
+	if size <= 0 {
+		return
 	}
+	h.topicMu.Lock()
+	h.replayBufferSize = size
+	h.topicMu.Unlock()
 }
 
 // Run starts the hub's main event loop
 func (h *Hub) Run() {
 	for {
 		select {
-		case client := <-h.register:
+		case reg := <-h.register:
 			h.mu.Lock()
-			if _, exists := h.clients[client.hackathonID]; !exists {
-				h.clients[client.hackathonID] = make(map[*Client]bool)
+			if _, exists := h.clients[reg.hackathonID]; !exists {
+				h.clients[reg.hackathonID] = make(map[Subscriber]bool)
 			}
-			h.clients[client.hackathonID][client] = true
+			h.clients[reg.hackathonID][reg.subscriber] = true
+			total := len(h.clients[reg.hackathonID])
 			h.mu.Unlock()
-			log.Printf("Client registered for hackathon %s (total: %d)",
-				client.hackathonID, len(h.clients[client.hackathonID]))
+			metrics.HubClientsTotal.WithLabelValues(reg.hackathonID).Set(float64(total))
+			h.logger.Info("subscriber registered",
+				"client_id", reg.subscriber.ID(), "hackathon_id", reg.hackathonID, "total", total)
 
-		case client := <-h.unregister:
+		case reg := <-h.unregister:
 			h.mu.Lock()
-			if clients, exists := h.clients[client.hackathonID]; exists {
-				if _, ok := clients[client]; ok {
-					delete(clients, client)
-					close(client.send)
-					log.Printf("Client unregistered for hackathon %s (remaining: %d)",
-						client.hackathonID, len(clients))
+			removed := false
+			remaining := 0
+			if clients, exists := h.clients[reg.hackathonID]; exists {
+				if _, ok := clients[reg.subscriber]; ok {
+					delete(clients, reg.subscriber)
+					reg.subscriber.Close()
+					removed = true
+					remaining = len(clients)
 
 					// Remove empty hackathon map
-					if len(clients) == 0 {
-						delete(h.clients, client.hackathonID)
+					if remaining == 0 {
+						delete(h.clients, reg.hackathonID)
 					}
 				}
 			}
 			h.mu.Unlock()
+			if removed {
+				metrics.HubClientsTotal.WithLabelValues(reg.hackathonID).Set(float64(remaining))
+				h.logger.Info("subscriber unregistered",
+					"client_id", reg.subscriber.ID(), "hackathon_id", reg.hackathonID, "remaining", remaining)
+			}
 
 		case message := <-h.broadcast:
+			start := time.Now()
 			h.mu.RLock()
 			clients := h.clients[message.HackathonID]
 			h.mu.RUnlock()
 
-			// Broadcast to all clients for this hackathon
+			// Dispatch each subscriber's Deliver on its own goroutine, each
+			// receiving the filtered variant matching its Filter (if one
+			// was computed) and the payload variant its role is entitled
+			// to. Deliver can block this subscriber up to deliverTimeout;
+			// running it inline here would let one lagging client stall
+			// the run loop (and every other hackathon's broadcasts) behind
+			// it.
 			for client := range clients {
-				select {
-				case client.send <- message.Data:
-					// Message sent successfully
-				default:
-					// Client's send channel is full, close it
-					h.mu.Lock()
-					close(client.send)
-					delete(h.clients[message.HackathonID], client)
-					h.mu.Unlock()
-					log.Printf("Client send buffer full, disconnecting")
+				payloads := message.Payloads
+				if variant, ok := message.Variants[client.Filter().Key()]; ok {
+					payloads = variant
 				}
+				data := payloads.For(client.Role())
+				go h.deliverOrEvict(message.HackathonID, client, data)
 			}
+			metrics.HubBroadcastDuration.Observe(time.Since(start).Seconds())
 
 		case <-h.shutdown:
-			log.Println("Hub shutting down")
+			h.logger.Info("hub shutting down")
 			h.mu.Lock()
 			for hackathonID, clients := range h.clients {
 				for client := range clients {
-					close(client.send)
+					client.Close()
 				}
 				delete(h.clients, hackathonID)
 			}
@@ -109,29 +238,181 @@ func (h *Hub) Run() {
 	}
 }
 
-// Broadcast sends a message to all clients subscribed to a specific hackathon
-func (h *Hub) Broadcast(hackathonID string, data []byte) {
+// deliverOrEvict delivers data to client and, if it reports back-pressure,
+// evicts it from hackathonID. It runs on its own goroutine per call so a
+// client slow enough to make Deliver block can never hold up the run loop.
+func (h *Hub) deliverOrEvict(hackathonID string, client Subscriber, data []byte) {
+	if err := client.Deliver(data); err != nil {
+		h.mu.Lock()
+		delete(h.clients[hackathonID], client)
+		remaining := len(h.clients[hackathonID])
+		if remaining == 0 {
+			delete(h.clients, hackathonID)
+		}
+		h.mu.Unlock()
+		client.Close()
+		metrics.HubClientsTotal.WithLabelValues(hackathonID).Set(float64(remaining))
+		h.logger.Warn("subscriber lagging, disconnecting",
+			"client_id", client.ID(), "hackathon_id", hackathonID, "error", err)
+	}
+}
+
+// NextSequence returns the next sequence number for a hackathon's broadcast
+// stream. Callers should embed the returned value in the payload they pass
+// to Broadcast so clients can persist it as a replay cursor.
+func (h *Hub) NextSequence(hackathonID string) uint64 {
+	h.topicMu.Lock()
+	defer h.topicMu.Unlock()
+	t := h.topicLocked(hackathonID)
+	t.seq++
+	return t.seq
+}
+
+// Broadcast sends a message to all subscribers subscribed to a specific
+// hackathon and retains it in that hackathon's replay buffer under seq.
+func (h *Hub) Broadcast(hackathonID string, seq uint64, payloads Payloads) {
+	h.BroadcastFiltered(hackathonID, seq, payloads, nil)
+}
+
+// BroadcastFiltered is like Broadcast, but additionally carries per-Filter
+// payload variants. A subscriber whose Filter matches a key in variants
+// receives that variant instead of the unfiltered fallback; replay and
+// federation only ever see the fallback, since variants are a local-node
+// broadcast-time optimization, not part of the durable/relayed record.
+func (h *Hub) BroadcastFiltered(hackathonID string, seq uint64, fallback Payloads, variants FilteredPayloads) {
+	h.topicMu.Lock()
+	t := h.topicLocked(hackathonID)
+	t.buffer = append(t.buffer, bufferedMessage{seq: seq, payloads: fallback, storedAt: time.Now().UTC()})
+	if overflow := len(t.buffer) - h.replayBufferSize; overflow > 0 {
+		t.buffer = t.buffer[overflow:]
+	}
+	h.topicMu.Unlock()
+
+	select {
+	case h.broadcast <- &BroadcastMessage{
+		HackathonID: hackathonID,
+		Seq:         seq,
+		Payloads:    fallback,
+		Variants:    variants,
+	}:
+	default:
+		metrics.HubBroadcastDropped.Inc()
+		h.logger.Warn("broadcast channel full, dropping message", "hackathon_id", hackathonID, "seq", seq)
+	}
+
+	h.peer.publish(hackathonID, seq, fallback)
+}
+
+// ActiveFilters returns the distinct non-empty Filters currently in use by
+// subscribers of a hackathon, so a caller can precompute a filtered variant
+// for each one before broadcasting instead of filtering per-client.
+func (h *Hub) ActiveFilters(hackathonID string) []Filter {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var filters []Filter
+	for client := range h.clients[hackathonID] {
+		filter := client.Filter()
+		key := filter.Key()
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		filters = append(filters, filter)
+	}
+	return filters
+}
+
+// ingestRemote stores and fans out a broadcast relayed from another node by
+// its Peer, without re-publishing it back to the mesh. Envelopes at or
+// behind the sequence we've already recorded for hackathonID are dropped as
+// duplicates or stale.
+func (h *Hub) ingestRemote(hackathonID string, seq uint64, payloads Payloads) {
+	h.topicMu.Lock()
+	t := h.topicLocked(hackathonID)
+	if seq <= t.seq {
+		h.topicMu.Unlock()
+		return
+	}
+	t.seq = seq
+	t.buffer = append(t.buffer, bufferedMessage{seq: seq, payloads: payloads, storedAt: time.Now().UTC()})
+	if overflow := len(t.buffer) - h.replayBufferSize; overflow > 0 {
+		t.buffer = t.buffer[overflow:]
+	}
+	h.topicMu.Unlock()
+
 	select {
 	case h.broadcast <- &BroadcastMessage{
 		HackathonID: hackathonID,
-		Data:        data,
+		Seq:         seq,
+		Payloads:    payloads,
 	}:
 	default:
-		log.Println("Broadcast channel full, dropping message")
+		metrics.HubBroadcastDropped.Inc()
+		h.logger.Warn("broadcast channel full, dropping relayed message", "hackathon_id", hackathonID, "seq", seq)
+	}
+}
+
+// topicLocked returns the topic for hackathonID, creating it if needed.
+// Callers must hold topicMu.
+func (h *Hub) topicLocked(hackathonID string) *topic {
+	t, exists := h.topics[hackathonID]
+	if !exists {
+		t = &topic{}
+		h.topics[hackathonID] = t
+	}
+	return t
+}
+
+// ReplaySince returns buffered broadcasts for hackathonID with a sequence
+// number greater than since, oldest first. It returns nil if nothing is
+// buffered or everything has already been seen.
+func (h *Hub) ReplaySince(hackathonID string, since uint64) []Payloads {
+	h.topicMu.Lock()
+	defer h.topicMu.Unlock()
+	t, exists := h.topics[hackathonID]
+	if !exists {
+		return nil
+	}
+	var out []Payloads
+	for _, m := range t.buffer {
+		if m.seq > since {
+			out = append(out, m.payloads)
+		}
+	}
+	return out
+}
+
+// ReplaySinceTime returns buffered broadcasts for hackathonID stored after
+// since, oldest first.
+func (h *Hub) ReplaySinceTime(hackathonID string, since time.Time) []Payloads {
+	h.topicMu.Lock()
+	defer h.topicMu.Unlock()
+	t, exists := h.topics[hackathonID]
+	if !exists {
+		return nil
+	}
+	var out []Payloads
+	for _, m := range t.buffer {
+		if m.storedAt.After(since) {
+			out = append(out, m.payloads)
+		}
 	}
+	return out
 }
 
-// Register adds a client to the hub
-func (h *Hub) Register(client *Client) {
-	h.register <- client
+// Register adds a subscriber to the hub for a given hackathon
+func (h *Hub) Register(hackathonID string, subscriber Subscriber) {
+	h.register <- registration{hackathonID: hackathonID, subscriber: subscriber}
 }
 
-// Unregister removes a client from the hub
-func (h *Hub) Unregister(client *Client) {
-	h.unregister <- client
+// Unregister removes a subscriber from the hub for a given hackathon
+func (h *Hub) Unregister(hackathonID string, subscriber Subscriber) {
+	h.unregister <- registration{hackathonID: hackathonID, subscriber: subscriber}
 }
 
-// GetClientCount returns the number of clients for a specific hackathon
+// GetClientCount returns the number of subscribers for a specific hackathon
 func (h *Hub) GetClientCount(hackathonID string) int {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -141,7 +422,7 @@ func (h *Hub) GetClientCount(hackathonID string) int {
 	return 0
 }
 
-// GetTotalClientCount returns the total number of connected clients
+// GetTotalClientCount returns the total number of connected subscribers
 func (h *Hub) GetTotalClientCount() int {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
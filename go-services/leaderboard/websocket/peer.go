@@ -0,0 +1,181 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"leaderboard/peer"
+)
+
+// Peer mirrors this Hub's broadcasts to other instances of the service over
+// a pluggable peer.Transport, and replays their broadcasts back into the
+// local fan-out, so a client connected to any node sees every node's
+// updates. This lets horizontally scaled replicas share one logical Hub
+// even though only one of them needs to consume ZeroDB events.
+type Peer struct {
+	nodeID    string
+	hub       *Hub
+	transport peer.Transport
+
+	mu    sync.RWMutex
+	seen  map[string]time.Time
+	watch map[string]bool
+}
+
+// NewPeer creates a Peer identified by nodeID, mirroring hub's broadcasts
+// over transport.
+func NewPeer(nodeID string, hub *Hub, transport peer.Transport) *Peer {
+	return &Peer{
+		nodeID:    nodeID,
+		hub:       hub,
+		transport: transport,
+		seen:      make(map[string]time.Time),
+		watch:     make(map[string]bool),
+	}
+}
+
+// Run subscribes to the transport and mirrors remote envelopes into the
+// local Hub until ctx is cancelled. Envelopes tagged with our own nodeID are
+// suppressed so we never echo our own broadcasts back to ourselves.
+func (p *Peer) Run(ctx context.Context) error {
+	return p.transport.Subscribe(ctx, func(env peer.Envelope) {
+		p.touch(env.NodeID)
+		if env.NodeID == p.nodeID {
+			return
+		}
+		p.hub.ingestRemote(env.HackathonID, env.Seq, Payloads{
+			Public:     env.Public,
+			Privileged: env.Privileged,
+		})
+	})
+}
+
+// publish mirrors a local broadcast to the mesh, tagged with our node ID.
+// It is called by Hub.Broadcast and is a no-op on a nil Peer so the Hub can
+// be used standalone without federation configured.
+func (p *Peer) publish(hackathonID string, seq uint64, payloads Payloads) {
+	if p == nil {
+		return
+	}
+	env := peer.Envelope{
+		NodeID:      p.nodeID,
+		HackathonID: hackathonID,
+		Seq:         seq,
+		Public:      payloads.Public,
+		Privileged:  payloads.Privileged,
+	}
+	if err := p.transport.Publish(context.Background(), env); err != nil {
+		log.Printf("Failed to publish broadcast to peers: %v", err)
+	}
+}
+
+func (p *Peer) touch(nodeID string) {
+	p.mu.Lock()
+	p.seen[nodeID] = time.Now().UTC()
+	p.mu.Unlock()
+}
+
+// member describes one peer node as observed via the mesh.
+type member struct {
+	NodeID   string    `json:"node_id"`
+	LastSeen time.Time `json:"last_seen"`
+	Watched  bool      `json:"watched"`
+}
+
+func (p *Peer) members() []member {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	out := make([]member, 0, len(p.seen)+len(p.watch))
+	included := make(map[string]bool)
+	for id, ts := range p.seen {
+		out = append(out, member{NodeID: id, LastSeen: ts, Watched: p.watch[id]})
+		included[id] = true
+	}
+	for id := range p.watch {
+		if !included[id] {
+			out = append(out, member{NodeID: id, Watched: true})
+		}
+	}
+	return out
+}
+
+// ServePeers handles GET /v1/peers, listing this node's ID and every peer
+// it has observed (or been asked to watch) via the mesh.
+func (p *Peer) ServePeers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"node_id": p.nodeID,
+		"members": p.members(),
+	})
+}
+
+// jsonRPCRequest and jsonRPCResponse implement the minimal envelope needed
+// for the /v1/peers/subscribe JSON-RPC-style endpoint.
+type jsonRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      interface{}     `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type jsonRPCResponse struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      interface{}   `json:"id"`
+	Result  interface{}   `json:"result,omitempty"`
+	Error   *jsonRPCError `json:"error,omitempty"`
+}
+
+type jsonRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// ServePeersSubscribe handles POST /v1/peers/subscribe. It accepts a
+// peers.subscribe JSON-RPC call with a node_id param and marks that node as
+// one operators expect to hear from, so it shows up in ServePeers even
+// before it has published anything.
+func (p *Peer) ServePeersSubscribe(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req jsonRPCRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		json.NewEncoder(w).Encode(jsonRPCResponse{
+			JSONRPC: "2.0",
+			Error:   &jsonRPCError{Code: -32700, Message: "parse error"},
+		})
+		return
+	}
+
+	if req.Method != "peers.subscribe" {
+		json.NewEncoder(w).Encode(jsonRPCResponse{
+			JSONRPC: "2.0", ID: req.ID,
+			Error: &jsonRPCError{Code: -32601, Message: "method not found"},
+		})
+		return
+	}
+
+	var params struct {
+		NodeID string `json:"node_id"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.NodeID == "" {
+		json.NewEncoder(w).Encode(jsonRPCResponse{
+			JSONRPC: "2.0", ID: req.ID,
+			Error: &jsonRPCError{Code: -32602, Message: "invalid params: node_id required"},
+		})
+		return
+	}
+
+	p.mu.Lock()
+	p.watch[params.NodeID] = true
+	p.mu.Unlock()
+
+	json.NewEncoder(w).Encode(jsonRPCResponse{
+		JSONRPC: "2.0", ID: req.ID,
+		Result: map[string]string{"subscribed": params.NodeID},
+	})
+}
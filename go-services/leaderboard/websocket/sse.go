@@ -0,0 +1,154 @@
+package websocket
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"leaderboard/leaderboard"
+)
+
+var sseClientSeq int64
+
+// sseClient is a Server-Sent Events subscriber. It implements Subscriber so
+// it fans out through the same Hub as WebSocket clients, just over a plain
+// HTTP response instead of an upgraded connection.
+type sseClient struct {
+	id        string
+	role      Role
+	filter    Filter
+	send      chan []byte
+	closeOnce sync.Once
+}
+
+func newSSEClient(role Role, filter Filter) *sseClient {
+	id := atomic.AddInt64(&sseClientSeq, 1)
+	return &sseClient{
+		id:     fmt.Sprintf("sse-%d", id),
+		role:   role,
+		filter: filter,
+		send:   make(chan []byte, 256),
+	}
+}
+
+func (c *sseClient) ID() string { return c.id }
+
+func (c *sseClient) Role() Role { return c.role }
+
+func (c *sseClient) Filter() Filter { return c.filter }
+
+// Deliver waits up to deliverTimeout for room in the client's send buffer,
+// the same bounded grace period WebSocket clients get, before reporting
+// back-pressure. Unlike a WebSocket client, an SSE client has no lagging
+// mode: the first timed-out delivery returns an error and the Hub evicts it.
+func (c *sseClient) Deliver(data []byte) error {
+	timer := time.NewTimer(deliverTimeout)
+	defer timer.Stop()
+
+	select {
+	case c.send <- data:
+		return nil
+	case <-timer.C:
+		return errSlowConsumer
+	}
+}
+
+func (c *sseClient) Close() {
+	c.closeOnce.Do(func() { close(c.send) })
+}
+
+// ServeSSE streams leaderboard broadcasts for a hackathon as text/event-stream
+// frames off /sse/hackathons/{id}, giving dashboards, curl, and CI bots the
+// same live rankings WebSocket clients receive without needing a WS library.
+func ServeSSE(hub *Hub, calculator *leaderboard.Calculator, w http.ResponseWriter, r *http.Request) {
+	hackathonID := hackathonIDFromPath(r.URL.Path, "/sse/hackathons/")
+	if hackathonID == "" {
+		http.Error(w, "hackathon id required", http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	claims, err := sseAuthenticate(hackathonID, r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	client := newSSEClient(claims.Role, parseFilter(r))
+	hub.Register(hackathonID, client)
+	defer hub.Unregister(hackathonID, client)
+
+	if replayed := replayMessages(hub, hackathonID, r); len(replayed) > 0 {
+		for _, payloads := range replayed {
+			writeSSEFrame(w, payloads.For(client.Role()))
+		}
+		flusher.Flush()
+	} else {
+		sendSnapshot(client, calculator, hackathonID)
+		flushFrame(w, flusher, client.send)
+	}
+
+	ctx := r.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case data, ok := <-client.send:
+			if !ok {
+				return
+			}
+			writeSSEFrame(w, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// sseAuthenticate extracts a bearer token from the Authorization header or a
+// ?token= query parameter, since SSE clients have no subprotocol handshake
+// to negotiate one through. Absent either, the connection is public.
+func sseAuthenticate(hackathonID string, r *http.Request) (*Claims, error) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimPrefix(auth, "Bearer ")
+		}
+	}
+	if token == "" {
+		return &Claims{HackathonID: hackathonID, Role: RolePublic}, nil
+	}
+	return verifyToken(hackathonID, token)
+}
+
+// flushFrame drains and writes a single pending frame (the initial snapshot
+// queued by sendSnapshot) without blocking if nothing is queued yet.
+func flushFrame(w http.ResponseWriter, flusher http.Flusher, send chan []byte) {
+	select {
+	case data, ok := <-send:
+		if !ok {
+			return
+		}
+		writeSSEFrame(w, data)
+		flusher.Flush()
+	default:
+	}
+}
+
+func writeSSEFrame(w http.ResponseWriter, data []byte) {
+	if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+		log.Printf("Error writing SSE frame: %v", err)
+	}
+}